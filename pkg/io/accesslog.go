@@ -0,0 +1,229 @@
+package io
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"juno/third_party/forked/golang/glog"
+)
+
+// AccessLogConfig configures the structured per-accept JSON log a listener
+// writes in addition to its CAL/glog handshake events.
+type AccessLogConfig struct {
+	Enabled bool
+
+	// Path is the log file to write to; "" or "-" means stdout, in which
+	// case rotation is disabled.
+	Path string
+
+	// MaxSizeBytes rotates the file once it grows past this size. The
+	// rotated file is gzipped in place.
+	MaxSizeBytes int64
+
+	// SampleRate caps verbatim records to SampleRate events/sec; the rest
+	// are folded into a periodic aggregate record instead, so a handshake
+	// storm cannot make the access log dominate accept latency.
+	SampleRate int
+}
+
+// AccessLogRecord is one accept's worth of structured, forensic detail.
+type AccessLogRecord struct {
+	Ts            time.Time `json:"ts"`
+	RAddr         string    `json:"raddr"`
+	LAddr         string    `json:"laddr"`
+	TLSVersion    string    `json:"tls_version,omitempty"`
+	Cipher        string    `json:"cipher,omitempty"`
+	SNI           string    `json:"sni,omitempty"`
+	ALPN          string    `json:"alpn,omitempty"`
+	ClientCertCN  string    `json:"client_cert_cn,omitempty"`
+	HandshakeMs   float64   `json:"handshake_ms"`
+	Outcome       string    `json:"outcome"`
+	ErrClass      string    `json:"err_class,omitempty"`
+}
+
+// accessLogAggregate is the periodic rollup emitted for events dropped by
+// the sampler.
+type accessLogAggregate struct {
+	Ts       time.Time      `json:"ts"`
+	Window   string         `json:"window"`
+	Dropped  int64          `json:"dropped"`
+	Outcomes map[string]int64 `json:"outcomes"`
+}
+
+// accessLog is a JSON-lines sink with size-based rotation (gzipping the
+// rotated file) and a token-bucket sampler so that under a handshake storm
+// only a bounded rate of records are written verbatim.
+type accessLog struct {
+	config AccessLogConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	enc      *json.Encoder
+
+	tokens     float64
+	lastRefill time.Time
+
+	dropMu   sync.Mutex
+	dropped  int64
+	outcomes map[string]int64
+}
+
+func newAccessLog(cfg AccessLogConfig) (*accessLog, error) {
+	l := &accessLog{
+		config:     cfg,
+		tokens:     float64(cfg.SampleRate),
+		lastRefill: time.Now(),
+		outcomes:   make(map[string]int64),
+	}
+
+	if cfg.Path == "" || cfg.Path == "-" {
+		l.file = os.Stdout
+	} else {
+		f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+		if info, err := f.Stat(); err == nil {
+			l.size = info.Size()
+		}
+	}
+	l.enc = json.NewEncoder(l.file)
+
+	if cfg.SampleRate > 0 {
+		go l.reportAggregatesPeriodically(1 * time.Second)
+	}
+	return l, nil
+}
+
+// allow implements a simple token bucket: up to config.SampleRate
+// events/sec are allowed through verbatim; the rest are counted instead.
+func (l *accessLog) allow() bool {
+	if l.config.SampleRate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(l.lastRefill).Seconds()
+	l.lastRefill = now
+	l.tokens += elapsed * float64(l.config.SampleRate)
+	if l.tokens > float64(l.config.SampleRate) {
+		l.tokens = float64(l.config.SampleRate)
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func (l *accessLog) Write(rec AccessLogRecord) {
+	l.mu.Lock()
+	allowed := l.allow()
+	l.mu.Unlock()
+
+	if !allowed {
+		l.dropMu.Lock()
+		l.dropped++
+		l.outcomes[rec.Outcome]++
+		l.dropMu.Unlock()
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(rec); err != nil {
+		glog.Warning("access log write failed: ", err)
+		return
+	}
+	if b, err := json.Marshal(rec); err == nil {
+		l.size += int64(len(b)) + 1
+	}
+	l.rotateIfNeeded()
+}
+
+func (l *accessLog) reportAggregatesPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.dropMu.Lock()
+		if l.dropped == 0 {
+			l.dropMu.Unlock()
+			continue
+		}
+		agg := accessLogAggregate{
+			Ts:       time.Now(),
+			Window:   interval.String(),
+			Dropped:  l.dropped,
+			Outcomes: l.outcomes,
+		}
+		l.dropped = 0
+		l.outcomes = make(map[string]int64)
+		l.dropMu.Unlock()
+
+		l.mu.Lock()
+		if err := l.enc.Encode(agg); err != nil {
+			glog.Warning("access log aggregate write failed: ", err)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// rotateIfNeeded gzips the current file and starts a fresh one once the
+// configured size threshold is exceeded. Must be called with l.mu held.
+func (l *accessLog) rotateIfNeeded() {
+	if l.config.MaxSizeBytes <= 0 || l.size < l.config.MaxSizeBytes || l.file == os.Stdout {
+		return
+	}
+
+	l.file.Close()
+
+	rotatedPath := fmt.Sprintf("%s.%d", l.config.Path, time.Now().UnixNano())
+	if err := os.Rename(l.config.Path, rotatedPath); err != nil {
+		glog.Warning("access log rotate failed: ", err)
+	} else {
+		go gzipAndRemove(rotatedPath)
+	}
+
+	f, err := os.OpenFile(l.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		glog.Error("access log reopen failed: ", err)
+		return
+	}
+	l.file = f
+	l.enc = json.NewEncoder(f)
+	l.size = 0
+}
+
+func gzipAndRemove(path string) {
+	in, err := os.Open(path)
+	if err != nil {
+		glog.Warning("access log gzip open failed: ", err)
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		glog.Warning("access log gzip create failed: ", err)
+		return
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		glog.Warning("access log gzip write failed: ", err)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		glog.Warning("access log gzip close failed: ", err)
+		return
+	}
+	os.Remove(path)
+}
@@ -0,0 +1,109 @@
+package io
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogAllowUnlimitedWhenSampleRateZero(t *testing.T) {
+	l := &accessLog{config: AccessLogConfig{SampleRate: 0}}
+	for i := 0; i < 10; i++ {
+		if !l.allow() {
+			t.Fatal("allow() = false, want true when SampleRate is disabled")
+		}
+	}
+}
+
+func TestAccessLogAllowCapsBurstToSampleRate(t *testing.T) {
+	l := &accessLog{
+		config:     AccessLogConfig{SampleRate: 5},
+		tokens:     5,
+		lastRefill: time.Now(),
+	}
+
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		if l.allow() {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Fatalf("allowed = %d, want 5 (no time elapsed, so no refill beyond the initial burst)", allowed)
+	}
+}
+
+func TestAccessLogAllowRefillsOverTime(t *testing.T) {
+	l := &accessLog{
+		config:     AccessLogConfig{SampleRate: 10},
+		tokens:     0,
+		lastRefill: time.Now().Add(-1 * time.Second),
+	}
+
+	if !l.allow() {
+		t.Fatal("allow() = false, want true after a full second's worth of refill at 10/sec")
+	}
+}
+
+func TestAccessLogWriteCountsDroppedRecords(t *testing.T) {
+	l := &accessLog{
+		config:     AccessLogConfig{SampleRate: 1},
+		tokens:     0,
+		lastRefill: time.Now(),
+		outcomes:   make(map[string]int64),
+	}
+
+	// With no tokens and no elapsed time to refill, every Write is dropped
+	// rather than reaching the (unset) encoder.
+	l.Write(AccessLogRecord{Outcome: "success"})
+	l.Write(AccessLogRecord{Outcome: "success"})
+
+	if l.dropped != 2 {
+		t.Fatalf("dropped = %d, want 2", l.dropped)
+	}
+	if l.outcomes["success"] != 2 {
+		t.Fatalf("outcomes[success] = %d, want 2", l.outcomes["success"])
+	}
+}
+
+func TestGzipAndRemovePreservesFullContent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	// Large enough that a short Read (the bug this guards against) would
+	// truncate the gzipped content.
+	want := strings.Repeat("a log line\n", 10000)
+	if err := os.WriteFile(path, []byte(want), 0644); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+
+	gzipAndRemove(path)
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("original file still exists after gzipAndRemove: err=%v", err)
+	}
+
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		t.Fatalf("open gz output: %s", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %s", err)
+	}
+	defer gz.Close()
+
+	got, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("read gzip content: %s", err)
+	}
+	if string(got) != want {
+		t.Fatalf("gzipped content length = %d, want %d (content truncated)", len(got), len(want))
+	}
+}
@@ -0,0 +1,376 @@
+package io
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	"juno/third_party/forked/golang/glog"
+)
+
+// jwksHTTPClient is used for both OIDC discovery and JWKS fetches; a short
+// timeout keeps a slow/unreachable issuer from stalling the refresh
+// goroutine or a connection's handshake path.
+var jwksHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// AuthConfig enables bearer-JWT authentication on a listener: after the TLS
+// handshake completes, the client must present a token issued by IssuerURL
+// before its connection is handed to startNewConnector.
+type AuthConfig struct {
+	Enabled bool
+
+	IssuerURL string
+	Audience  string
+
+	// PrincipalClaim names the claim used as the connection's principal.
+	// Defaults to "sub".
+	PrincipalClaim string
+
+	// NamespaceClaim names the claim carrying the list of namespaces the
+	// principal is allowed to access. Defaults to "juno_ns".
+	NamespaceClaim string
+
+	// Timeout bounds how long AcceptAndServe waits for the client's bearer
+	// token line after a successful handshake. Defaults to 2s.
+	Timeout time.Duration
+}
+
+func (c *AuthConfig) timeout() time.Duration {
+	if c == nil || c.Timeout == 0 {
+		return 2 * time.Second
+	}
+	return c.Timeout
+}
+
+func (c *AuthConfig) principalClaim() string {
+	if c == nil || c.PrincipalClaim == "" {
+		return "sub"
+	}
+	return c.PrincipalClaim
+}
+
+func (c *AuthConfig) namespaceClaim() string {
+	if c == nil || c.NamespaceClaim == "" {
+		return "juno_ns"
+	}
+	return c.NamespaceClaim
+}
+
+// AuthPrincipal is the identity extracted from a validated bearer token. It
+// is attached to the accepted connection so request handlers further down
+// the stack can enforce per-namespace ACLs.
+type AuthPrincipal struct {
+	Subject    string
+	Namespaces []string
+}
+
+// authenticatedConn threads an AuthPrincipal alongside the underlying
+// net.Conn, mirroring the quicStreamConn adapter used for QUIC streams.
+type authenticatedConn struct {
+	net.Conn
+	Principal *AuthPrincipal
+}
+
+// jwksCache caches an OIDC issuer's JSON Web Key Set, refreshing it in the
+// background on a jittered interval and on-demand when an unknown `kid` is
+// seen, so a transient issuer outage doesn't immediately break new
+// connections.
+type jwksCache struct {
+	issuerURL string
+
+	mu   sync.RWMutex
+	keys map[string]interface{}
+
+	refreshInterval time.Duration
+
+	stop chan struct{}
+}
+
+func newJWKSCache(issuerURL string, refreshInterval time.Duration) *jwksCache {
+	if refreshInterval == 0 {
+		refreshInterval = 10 * time.Minute
+	}
+	c := &jwksCache{
+		issuerURL:       issuerURL,
+		keys:            make(map[string]interface{}),
+		refreshInterval: refreshInterval,
+		stop:            make(chan struct{}),
+	}
+	if err := c.refresh(); err != nil {
+		glog.Warning("initial jwks fetch failed: ", err)
+	}
+	go c.run()
+	return c
+}
+
+func (c *jwksCache) run() {
+	for {
+		jitter := time.Duration(rand.Int63n(int64(c.refreshInterval) / 2))
+		select {
+		case <-time.After(c.refreshInterval + jitter):
+			if err := c.refresh(); err != nil {
+				glog.Warning("jwks background refresh failed: ", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *jwksCache) Close() {
+	close(c.stop)
+}
+
+// refresh fetches the issuer's discovery document and JWKS, replacing the
+// cached key set on success. On failure the previously cached keys are kept.
+func (c *jwksCache) refresh() error {
+	keys, err := fetchJWKS(c.issuerURL)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *jwksCache) key(kid string) (interface{}, bool) {
+	c.mu.RLock()
+	k, ok := c.keys[kid]
+	c.mu.RUnlock()
+	if ok {
+		return k, true
+	}
+
+	// kid miss: refresh once synchronously in case of key rotation.
+	if err := c.refresh(); err != nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	k, ok = c.keys[kid]
+	c.mu.RUnlock()
+	return k, ok
+}
+
+// oidcDiscoveryDoc is the subset of an OIDC discovery document this package
+// cares about.
+type oidcDiscoveryDoc struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jwk is a single entry of a JSON Web Key Set, covering the RSA and EC key
+// types issuers commonly publish.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// fetchJWKS retrieves the issuer's JWKS document via OIDC discovery,
+// returning the verifiable public keys it contains keyed by `kid`.
+func fetchJWKS(issuerURL string) (map[string]interface{}, error) {
+	jwksURI, err := discoverJWKSURI(issuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := jwksHTTPClient.Get(jwksURI)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: fetch %s: %s", jwksURI, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetch %s: status %d", jwksURI, resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("jwks: decode %s: %s", jwksURI, err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			glog.Warning("jwks: skipping kid ", k.Kid, ": ", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// discoverJWKSURI fetches issuerURL's "/.well-known/openid-configuration"
+// and returns the jwks_uri it advertises.
+func discoverJWKSURI(issuerURL string) (string, error) {
+	resp, err := jwksHTTPClient.Get(strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("jwks: discovery: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("jwks: discovery: status %d", resp.StatusCode)
+	}
+
+	var doc oidcDiscoveryDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("jwks: discovery decode: %s", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("jwks: discovery document missing jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// publicKey decodes k into the *rsa.PublicKey or *ecdsa.PublicKey jwt.Parse
+// expects as a verification key.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode n: %s", err)
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode e: %s", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}, nil
+	case "EC":
+		curve, err := ecCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %s", err)
+		}
+		yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y: %s", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
+
+func ecCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported crv %q", crv)
+	}
+}
+
+// bufferedConn wraps a net.Conn whose first bytes were consumed through br
+// (typically while reading the bearer-token line) so that anything the
+// client already pipelined past that line isn't lost: Read drains br's
+// buffer before falling through to the underlying conn.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.br.Read(b)
+}
+
+// readBearerToken reads a single "Bearer <token>\n" line off conn. Clients
+// are expected to send it immediately after the TLS handshake, before any
+// protocol traffic. It returns a net.Conn that replays any bytes already
+// buffered past the token line, since conn itself may have been read ahead.
+func readBearerToken(conn net.Conn) (string, net.Conn, error) {
+	r := bufio.NewReaderSize(conn, 4096)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", nil, err
+	}
+	line = strings.TrimSpace(line)
+	const prefix = "Bearer "
+	if !strings.HasPrefix(line, prefix) {
+		return "", nil, fmt.Errorf("missing bearer token")
+	}
+	return strings.TrimPrefix(line, prefix), &bufferedConn{Conn: conn, br: r}, nil
+}
+
+// authenticate validates the bearer token presented on conn against cfg and
+// the listener's jwksCache, returning the extracted principal and a conn to
+// use in place of the caller's that preserves any bytes read ahead while
+// looking for the token line.
+func authenticate(conn net.Conn, cfg *AuthConfig, cache *jwksCache) (*AuthPrincipal, net.Conn, error) {
+	raw, bufConn, err := readBearerToken(conn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("jwt: %s", err)
+	}
+
+	token, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := cache.key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	}, jwt.WithIssuer(cfg.IssuerURL), jwt.WithAudience(cfg.Audience))
+	if err != nil {
+		return nil, nil, fmt.Errorf("jwt: %s", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, nil, fmt.Errorf("jwt: invalid claims")
+	}
+
+	sub, _ := claims[cfg.principalClaim()].(string)
+	if sub == "" {
+		return nil, nil, fmt.Errorf("jwt: missing %s claim", cfg.principalClaim())
+	}
+
+	var namespaces []string
+	if raw, ok := claims[cfg.namespaceClaim()].([]interface{}); ok {
+		for _, v := range raw {
+			if ns, ok := v.(string); ok {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+
+	return &AuthPrincipal{Subject: sub, Namespaces: namespaces}, bufConn, nil
+}
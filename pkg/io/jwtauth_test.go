@@ -0,0 +1,127 @@
+package io
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthConfigTimeoutDefault(t *testing.T) {
+	var cfg *AuthConfig
+	if got := cfg.timeout(); got != 2*time.Second {
+		t.Fatalf("timeout() = %s, want 2s for a nil config", got)
+	}
+
+	cfg = &AuthConfig{}
+	if got := cfg.timeout(); got != 2*time.Second {
+		t.Fatalf("timeout() = %s, want 2s default", got)
+	}
+}
+
+func TestAuthConfigTimeoutOverride(t *testing.T) {
+	cfg := &AuthConfig{Timeout: 5 * time.Second}
+	if got := cfg.timeout(); got != 5*time.Second {
+		t.Fatalf("timeout() = %s, want 5s", got)
+	}
+}
+
+func TestReadBearerTokenPreservesTrailingBytes(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		client.Write([]byte("Bearer abc.def.ghi\nEXTRA"))
+	}()
+
+	token, bufConn, err := readBearerToken(server)
+	if err != nil {
+		t.Fatalf("readBearerToken: %s", err)
+	}
+	if token != "abc.def.ghi" {
+		t.Fatalf("token = %q, want %q", token, "abc.def.ghi")
+	}
+
+	buf := make([]byte, len("EXTRA"))
+	if _, err := io.ReadFull(bufConn, buf); err != nil {
+		t.Fatalf("reading trailing bytes: %s", err)
+	}
+	if string(buf) != "EXTRA" {
+		t.Fatalf("trailing bytes = %q, want %q", buf, "EXTRA")
+	}
+}
+
+func TestJWKPublicKeyRSA(t *testing.T) {
+	k := jwk{
+		Kty: "RSA",
+		Kid: "test-key",
+		N:   base64.RawURLEncoding.EncodeToString([]byte{0x01, 0x00, 0x01, 0x02}),
+		E:   base64.RawURLEncoding.EncodeToString([]byte{0x01, 0x00, 0x01}),
+	}
+	key, err := k.publicKey()
+	if err != nil {
+		t.Fatalf("publicKey: %s", err)
+	}
+	if _, ok := key.(interface{ Size() int }); !ok {
+		t.Fatalf("publicKey returned %T, want something with a Size() method (*rsa.PublicKey)", key)
+	}
+}
+
+func TestJWKPublicKeyUnsupportedKty(t *testing.T) {
+	k := jwk{Kty: "oct"}
+	if _, err := k.publicKey(); err == nil {
+		t.Fatal("expected error for unsupported kty")
+	}
+}
+
+func TestFetchJWKSViaDiscovery(t *testing.T) {
+	jwksSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys":[{"kty":"RSA","kid":"k1","n":"AQAB","e":"AQAB"}]}`))
+	}))
+	defer jwksSrv.Close()
+
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jwks_uri":"` + jwksSrv.URL + `"}`))
+	}))
+	defer issuer.Close()
+
+	keys, err := fetchJWKS(issuer.URL)
+	if err != nil {
+		t.Fatalf("fetchJWKS: %s", err)
+	}
+	if _, ok := keys["k1"]; !ok {
+		t.Fatalf("keys = %v, want entry for kid k1", keys)
+	}
+}
+
+func TestJWKSCacheRefreshesOnKidMiss(t *testing.T) {
+	calls := 0
+	jwksSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		kid := "k1"
+		if calls > 1 {
+			kid = "k2"
+		}
+		w.Write([]byte(`{"keys":[{"kty":"RSA","kid":"` + kid + `","n":"AQAB","e":"AQAB"}]}`))
+	}))
+	defer jwksSrv.Close()
+
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jwks_uri":"` + jwksSrv.URL + `"}`))
+	}))
+	defer issuer.Close()
+
+	c := newJWKSCache(issuer.URL, 0)
+	defer c.Close()
+
+	if _, ok := c.key("k2"); !ok {
+		t.Fatal("expected kid miss to trigger a synchronous refresh that picks up k2")
+	}
+	if calls < 2 {
+		t.Fatalf("calls = %d, want at least 2 (initial fetch + kid-miss refresh)", calls)
+	}
+}
@@ -0,0 +1,139 @@
+package io
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	quic "github.com/quic-go/quic-go"
+
+	"juno/third_party/forked/golang/glog"
+
+	"juno/pkg/logging"
+	"juno/pkg/logging/cal"
+	"juno/pkg/sec"
+)
+
+// alpnJuno is the ALPN token negotiated for juno's QUIC transport.
+const alpnJuno = "juno"
+
+// ListenerTypeQUIC identifies a listener that terminates QUIC/TLS1.3
+// connections and feeds individual streams into the connector layer as if
+// they were plain net.Conn, alongside ListenerTypeTCPwSSL.
+const ListenerTypeQUIC ListenerType = ListenerTypeTCPwSSL + 1
+
+// QuicListener terminates QUIC with TLS 1.3 and demultiplexes each
+// connection's streams to startNewConnector via a net.Conn adapter.
+type QuicListener struct {
+	Listener
+
+	quicListener *quic.Listener
+}
+
+func (l *QuicListener) AcceptAndServe() error {
+	if l.quicListener == nil {
+		tlsConfig, err := sec.NewServerTLSConfig()
+		if err != nil {
+			return err
+		}
+		tlsConfig.NextProtos = []string{alpnJuno}
+
+		if src := sec.ServerCertSource(); src != nil {
+			tlsConfig.GetCertificate = src.GetCertificate
+			if pool := src.CAPool(); pool != nil {
+				tlsConfig.ClientCAs = pool
+				tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+
+		// Allow0RTT lets returning clients resume with 0-RTT, skipping a
+		// round trip on reconnect.
+		ql, err := quic.ListenAddr(l.config.Addr, tlsConfig, &quic.Config{Allow0RTT: true})
+		if err != nil {
+			return err
+		}
+		l.quicListener = ql
+	}
+
+	conn, err := l.quicListener.Accept(context.Background())
+	if err != nil {
+		return err
+	}
+
+	go l.serveConn(conn)
+
+	return nil
+}
+
+// serveConn accepts every stream opened on a QUIC connection and hands each
+// one to startNewConnector through the net.Conn adapter. The CAL accept
+// event is logged once per connection, not per stream, so a high-fanout
+// client multiplexing many streams over one connection doesn't turn accept
+// logging into O(streams).
+func (l *QuicListener) serveConn(conn quic.Connection) {
+	startTime := time.Now()
+
+	if cal.IsEnabled() {
+		raddr := conn.RemoteAddr().String()
+		if rhost, _, e := net.SplitHostPort(raddr); e == nil {
+			b := logging.NewKVBuffer()
+			b.Add([]byte("raddr"), raddr).
+				Add([]byte("laddr"), conn.LocalAddr().String()).
+				Add([]byte("proto"), "quic").
+				Add([]byte("alpn"), conn.ConnectionState().TLS.NegotiatedProtocol).
+				Add([]byte("et"), time.Since(startTime).String())
+			cal.Event(cal.TxnTypeAccept, rhost, cal.StatusSuccess, b.Bytes())
+		}
+	}
+
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			glog.Debug("quic accept stream error: ", err)
+			return
+		}
+
+		l.startNewConnector(newQuicStreamConn(conn, stream))
+	}
+}
+
+func (l *QuicListener) GetType() ListenerType {
+	return ListenerTypeQUIC
+}
+
+func (l *QuicListener) Refresh() {
+	if l.quicListener != nil {
+		l.quicListener.Close()
+		l.quicListener = nil
+	}
+}
+
+// quicStreamConn adapts a quic.Stream (plus its parent connection, for
+// addressing) to the net.Conn interface expected by the connector layer.
+type quicStreamConn struct {
+	conn   quic.Connection
+	stream quic.Stream
+}
+
+func newQuicStreamConn(conn quic.Connection, stream quic.Stream) *quicStreamConn {
+	return &quicStreamConn{conn: conn, stream: stream}
+}
+
+func (c *quicStreamConn) Read(b []byte) (int, error)  { return c.stream.Read(b) }
+func (c *quicStreamConn) Write(b []byte) (int, error) { return c.stream.Write(b) }
+func (c *quicStreamConn) Close() error                { return c.stream.Close() }
+func (c *quicStreamConn) LocalAddr() net.Addr          { return c.conn.LocalAddr() }
+func (c *quicStreamConn) RemoteAddr() net.Addr         { return c.conn.RemoteAddr() }
+
+func (c *quicStreamConn) SetDeadline(t time.Time) error {
+	return c.stream.SetDeadline(t)
+}
+
+func (c *quicStreamConn) SetReadDeadline(t time.Time) error {
+	return c.stream.SetReadDeadline(t)
+}
+
+func (c *quicStreamConn) SetWriteDeadline(t time.Time) error {
+	return c.stream.SetWriteDeadline(t)
+}
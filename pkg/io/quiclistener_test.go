@@ -0,0 +1,148 @@
+package io
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	quic "github.com/quic-go/quic-go"
+)
+
+// fakeQuicStream implements quic.Stream by embedding the (nil) interface and
+// overriding only the methods quicStreamConn calls, the standard idiom for a
+// partial fake of a large third-party interface.
+type fakeQuicStream struct {
+	quic.Stream
+
+	readBuf  []byte
+	readErr  error
+	written  []byte
+	closed   bool
+	deadline time.Time
+	rDead    time.Time
+	wDead    time.Time
+}
+
+func (f *fakeQuicStream) Read(b []byte) (int, error) {
+	if f.readErr != nil {
+		return 0, f.readErr
+	}
+	n := copy(b, f.readBuf)
+	return n, nil
+}
+
+func (f *fakeQuicStream) Write(b []byte) (int, error) {
+	f.written = append(f.written, b...)
+	return len(b), nil
+}
+
+func (f *fakeQuicStream) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeQuicStream) SetDeadline(t time.Time) error {
+	f.deadline = t
+	return nil
+}
+
+func (f *fakeQuicStream) SetReadDeadline(t time.Time) error {
+	f.rDead = t
+	return nil
+}
+
+func (f *fakeQuicStream) SetWriteDeadline(t time.Time) error {
+	f.wDead = t
+	return nil
+}
+
+// fakeQuicConn implements quic.Connection the same way: embed the nil
+// interface, override only LocalAddr/RemoteAddr.
+type fakeQuicConn struct {
+	quic.Connection
+
+	local, remote net.Addr
+}
+
+func (c *fakeQuicConn) LocalAddr() net.Addr  { return c.local }
+func (c *fakeQuicConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestQuicStreamConnReadWriteClose(t *testing.T) {
+	stream := &fakeQuicStream{readBuf: []byte("hello")}
+	conn := newQuicStreamConn(&fakeQuicConn{}, stream)
+
+	buf := make([]byte, 5)
+	n, err := conn.Read(buf)
+	if err != nil || n != 5 || string(buf) != "hello" {
+		t.Fatalf("Read() = (%d, %v), buf=%q", n, err, buf)
+	}
+
+	if _, err := conn.Write([]byte("world")); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	if string(stream.written) != "world" {
+		t.Fatalf("written = %q, want %q", stream.written, "world")
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+	if !stream.closed {
+		t.Fatal("expected Close to close the underlying stream")
+	}
+}
+
+func TestQuicStreamConnReadPropagatesError(t *testing.T) {
+	wantErr := errors.New("stream error")
+	stream := &fakeQuicStream{readErr: wantErr}
+	conn := newQuicStreamConn(&fakeQuicConn{}, stream)
+
+	if _, err := conn.Read(make([]byte, 1)); err != wantErr {
+		t.Fatalf("Read() err = %v, want %v", err, wantErr)
+	}
+}
+
+func TestQuicStreamConnAddrsDelegateToConnection(t *testing.T) {
+	local := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5678}
+	conn := newQuicStreamConn(&fakeQuicConn{local: local, remote: remote}, &fakeQuicStream{})
+
+	if conn.LocalAddr() != local {
+		t.Fatalf("LocalAddr() = %v, want %v", conn.LocalAddr(), local)
+	}
+	if conn.RemoteAddr() != remote {
+		t.Fatalf("RemoteAddr() = %v, want %v", conn.RemoteAddr(), remote)
+	}
+}
+
+func TestQuicStreamConnDeadlinesDelegateToStream(t *testing.T) {
+	stream := &fakeQuicStream{}
+	conn := newQuicStreamConn(&fakeQuicConn{}, stream)
+
+	now := time.Now()
+	if err := conn.SetDeadline(now); err != nil || !stream.deadline.Equal(now) {
+		t.Fatalf("SetDeadline: err=%v, deadline=%v", err, stream.deadline)
+	}
+	if err := conn.SetReadDeadline(now); err != nil || !stream.rDead.Equal(now) {
+		t.Fatalf("SetReadDeadline: err=%v, deadline=%v", err, stream.rDead)
+	}
+	if err := conn.SetWriteDeadline(now); err != nil || !stream.wDead.Equal(now) {
+		t.Fatalf("SetWriteDeadline: err=%v, deadline=%v", err, stream.wDead)
+	}
+}
+
+func TestQuicListenerGetType(t *testing.T) {
+	l := &QuicListener{}
+	if got := l.GetType(); got != ListenerTypeQUIC {
+		t.Fatalf("GetType() = %v, want %v", got, ListenerTypeQUIC)
+	}
+}
+
+func TestQuicListenerRefreshNilIsNoop(t *testing.T) {
+	l := &QuicListener{}
+	l.Refresh()
+	if l.quicListener != nil {
+		t.Fatal("expected quicListener to remain nil")
+	}
+}
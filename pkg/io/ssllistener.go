@@ -1,9 +1,11 @@
 package io
 
 import (
+	"crypto/tls"
 	"io"
 	"net"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,8 +16,91 @@ import (
 	"juno/pkg/sec"
 )
 
+// tlsStater is satisfied by sec.NewServerConn's return value (or anything
+// else wrapping a *tls.Conn), letting accessLogFields read the negotiated
+// TLS state without depending on pkg/sec's concrete connection type.
+type tlsStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// accessLogFields extracts the forensic detail AccessLogRecord carries from
+// v's negotiated TLS state, if v exposes one.
+func accessLogFields(v interface{}) (cipher, sni, alpn, clientCertCN string) {
+	stater, ok := v.(tlsStater)
+	if !ok {
+		return
+	}
+	cs := stater.ConnectionState()
+	cipher = tls.CipherSuiteName(cs.CipherSuite)
+	sni = cs.ServerName
+	alpn = cs.NegotiatedProtocol
+	if len(cs.PeerCertificates) > 0 {
+		clientCertCN = cs.PeerCertificates[0].Subject.CommonName
+	}
+	return
+}
+
 type SslListener struct {
 	Listener
+
+	authOnce  sync.Once
+	authCache *jwksCache
+
+	// certSource is non-nil when the listener's certificate material is
+	// sourced from Vault rather than on-disk files.
+	certSourceOnce sync.Once
+	certSource     *sec.VaultCertSource
+
+	accessLogOnce sync.Once
+	accessLogger  *accessLog
+}
+
+// accessLogFor lazily builds the listener's structured access log sink, if
+// configured.
+func (l *SslListener) accessLogFor() *accessLog {
+	l.accessLogOnce.Do(func() {
+		if cfg := l.ioConfig.AccessLog; cfg != nil && cfg.Enabled {
+			al, err := newAccessLog(*cfg)
+			if err != nil {
+				glog.Error("access log init failed: ", err)
+				return
+			}
+			l.accessLogger = al
+		}
+	})
+	return l.accessLogger
+}
+
+// authCacheFor lazily builds the JWKS cache for the listener's AuthConfig,
+// if JWT authentication is enabled.
+func (l *SslListener) authCacheFor() *jwksCache {
+	l.authOnce.Do(func() {
+		if auth := l.ioConfig.Auth; auth != nil && auth.Enabled {
+			l.authCache = newJWKSCache(auth.IssuerURL, 0)
+		}
+	})
+	return l.authCache
+}
+
+// certSourceFor lazily builds the listener's Vault-backed certificate
+// source, if configured, and installs it as the process-wide source
+// sec.NewServerConn's tls.Config.GetCertificate consults in place of the
+// statically configured certificate files.
+func (l *SslListener) certSourceFor() *sec.VaultCertSource {
+	l.certSourceOnce.Do(func() {
+		cfg := l.ioConfig.Vault
+		if cfg == nil || !cfg.Enabled {
+			return
+		}
+		src, err := sec.NewVaultCertSource(cfg)
+		if err != nil {
+			glog.Error("vault cert source init failed: ", err)
+			return
+		}
+		l.certSource = src
+		sec.SetServerCertSource(src)
+	})
+	return l.certSource
 }
 
 func (l *SslListener) AcceptAndServe() error {
@@ -32,10 +117,59 @@ func (l *SslListener) AcceptAndServe() error {
 		}
 		startTime := time.Now()
 
+		l.certSourceFor()
+
 		conn.SetReadDeadline(startTime.Add(handshakeTimeout))
 		if sslConn, err := sec.NewServerConn(conn); err == nil {
 
 			if err = sslConn.Handshake(); err == nil {
+				netConn := sslConn.GetNetConn()
+
+				var principal *AuthPrincipal
+				if auth := l.ioConfig.Auth; auth != nil && auth.Enabled {
+					// The bearer-token read gets its own deadline: the
+					// handshake deadline set above may already be close to
+					// expiring, and is sized for the handshake, not for a
+					// client that's merely slow to send its token.
+					netConn.SetReadDeadline(time.Now().Add(auth.timeout()))
+					p, bufConn, authErr := authenticate(netConn, auth, l.authCacheFor())
+					netConn.SetReadDeadline(time.Time{})
+					if authErr != nil {
+						if cal.IsEnabled() {
+							raddr := conn.RemoteAddr().String()
+							if rhost, _, e := net.SplitHostPort(raddr); e == nil {
+								b := logging.NewKVBuffer()
+								b.Add([]byte("raddr"), raddr).
+									Add([]byte("laddr"), conn.LocalAddr().String()).
+									Add([]byte("et"), time.Since(startTime).String()).
+									Add([]byte("err"), "\""+authErr.Error()+"\"")
+								cal.Event(cal.TxnTypeAccept, rhost, cal.StatusWarning, b.Bytes())
+							}
+						}
+						glog.Warning("jwt auth failed: ", authErr)
+						if al := l.accessLogFor(); al != nil {
+							cipher, sni, alpn, clientCertCN := accessLogFields(sslConn)
+							al.Write(AccessLogRecord{
+								Ts:           startTime,
+								RAddr:        conn.RemoteAddr().String(),
+								LAddr:        conn.LocalAddr().String(),
+								TLSVersion:   sslConn.GetStateString(),
+								Cipher:       cipher,
+								SNI:          sni,
+								ALPN:         alpn,
+								ClientCertCN: clientCertCN,
+								HandshakeMs:  float64(time.Since(startTime).Microseconds()) / 1000.0,
+								Outcome:      "auth_error",
+								ErrClass:     authErr.Error(),
+							})
+						}
+						netConn.Close()
+						return
+					}
+					principal = p
+					netConn = &authenticatedConn{Conn: bufConn, Principal: principal}
+				}
+
 				if cal.IsEnabled() {
 					raddr := conn.RemoteAddr().String()
 					if rhost, _, e := net.SplitHostPort(raddr); e == nil {
@@ -47,7 +181,22 @@ func (l *SslListener) AcceptAndServe() error {
 						cal.Event(cal.TxnTypeAccept, rhost, cal.StatusSuccess, b.Bytes())
 					}
 				}
-				l.startNewConnector(sslConn.GetNetConn())
+				if al := l.accessLogFor(); al != nil {
+					cipher, sni, alpn, clientCertCN := accessLogFields(sslConn)
+					al.Write(AccessLogRecord{
+						Ts:           startTime,
+						RAddr:        conn.RemoteAddr().String(),
+						LAddr:        conn.LocalAddr().String(),
+						TLSVersion:   sslConn.GetStateString(),
+						Cipher:       cipher,
+						SNI:          sni,
+						ALPN:         alpn,
+						ClientCertCN: clientCertCN,
+						HandshakeMs:  float64(time.Since(startTime).Microseconds()) / 1000.0,
+						Outcome:      "success",
+					})
+				}
+				l.startNewConnector(netConn)
 			} else {
 				logAsWarning := true
 
@@ -81,6 +230,16 @@ func (l *SslListener) AcceptAndServe() error {
 				} else {
 					glog.Debug("handshaking error: ", err)
 				}
+				if al := l.accessLogFor(); al != nil {
+					al.Write(AccessLogRecord{
+						Ts:          startTime,
+						RAddr:       conn.RemoteAddr().String(),
+						LAddr:       conn.LocalAddr().String(),
+						HandshakeMs: float64(time.Since(startTime).Microseconds()) / 1000.0,
+						Outcome:     "handshake_error",
+						ErrClass:    err.Error(),
+					})
+				}
 			}
 		}
 	}()
@@ -99,4 +258,10 @@ func (l *SslListener) Refresh() {
 	if err != nil {
 		glog.Error(err)
 	}
+
+	if l.certSource != nil {
+		if err := l.certSource.ReloadNow(); err != nil {
+			glog.Warning("vault cert reload on refresh failed: ", err)
+		}
+	}
 }
@@ -0,0 +1,46 @@
+package io
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+type fakeTLSStater struct {
+	cs tls.ConnectionState
+}
+
+func (f fakeTLSStater) ConnectionState() tls.ConnectionState { return f.cs }
+
+func TestAccessLogFieldsExtractsTLSState(t *testing.T) {
+	stater := fakeTLSStater{cs: tls.ConnectionState{
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "db.example.com",
+		NegotiatedProtocol: "juno",
+		PeerCertificates: []*x509.Certificate{
+			{Subject: pkix.Name{CommonName: "client.example.com"}},
+		},
+	}}
+
+	cipher, sni, alpn, clientCertCN := accessLogFields(stater)
+	if cipher != tls.CipherSuiteName(tls.TLS_AES_128_GCM_SHA256) {
+		t.Fatalf("cipher = %q, want %q", cipher, tls.CipherSuiteName(tls.TLS_AES_128_GCM_SHA256))
+	}
+	if sni != "db.example.com" {
+		t.Fatalf("sni = %q, want %q", sni, "db.example.com")
+	}
+	if alpn != "juno" {
+		t.Fatalf("alpn = %q, want %q", alpn, "juno")
+	}
+	if clientCertCN != "client.example.com" {
+		t.Fatalf("clientCertCN = %q, want %q", clientCertCN, "client.example.com")
+	}
+}
+
+func TestAccessLogFieldsNonTLSConnReturnsEmpty(t *testing.T) {
+	cipher, sni, alpn, clientCertCN := accessLogFields("not a tls conn")
+	if cipher != "" || sni != "" || alpn != "" || clientCertCN != "" {
+		t.Fatalf("expected all-empty fields for a non-TLS value, got %q %q %q %q", cipher, sni, alpn, clientCertCN)
+	}
+}
@@ -0,0 +1,328 @@
+package sec
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"juno/third_party/forked/golang/glog"
+	"juno/pkg/logging/cal"
+)
+
+// serverCertSource, when set, supplies the server's TLS certificate via
+// Vault instead of the on-disk files NewServerConn/NewServerTLSConfig load
+// by default. SetServerCertSource installs it once at listener startup;
+// NewServerConn's tls.Config.GetCertificate consults it in preference to
+// the static files when non-nil.
+var serverCertSource *VaultCertSource
+
+// SetServerCertSource installs src as the process-wide source of server TLS
+// certificates. Passing nil reverts to the statically configured
+// certificate files.
+func SetServerCertSource(src *VaultCertSource) {
+	serverCertSource = src
+}
+
+// ServerCertSource returns the currently installed Vault certificate
+// source, or nil if none is configured.
+func ServerCertSource() *VaultCertSource {
+	return serverCertSource
+}
+
+// VaultAuthMethod selects how the loader authenticates to Vault.
+type VaultAuthMethod string
+
+const (
+	VaultAuthToken      VaultAuthMethod = "token"
+	VaultAuthAppRole    VaultAuthMethod = "approle"
+	VaultAuthKubernetes VaultAuthMethod = "kubernetes"
+)
+
+// VaultConfig configures sourcing server certificate material from Vault
+// instead of on-disk files, for SslListener and other sec.NewServerConn
+// consumers.
+type VaultConfig struct {
+	Enabled bool
+
+	Address    string
+	AuthMethod VaultAuthMethod
+
+	// Token is used when AuthMethod is VaultAuthToken.
+	Token string
+
+	// AppRole is used when AuthMethod is VaultAuthAppRole.
+	AppRoleID   string
+	AppSecretID string
+
+	// KubernetesRole and KubernetesJWTPath are used when AuthMethod is
+	// VaultAuthKubernetes; the JWT is read from KubernetesJWTPath (typically
+	// the projected service account token).
+	KubernetesRole    string
+	KubernetesJWTPath string
+
+	// PKIMountPath/PKIRole request a short-TTL leaf certificate from Vault's
+	// PKI secrets engine. Mutually exclusive with KVPath.
+	PKIMountPath string
+	PKIRole      string
+	CommonName   string
+
+	// KVPath reads a static cert/key/CA bundle from a KV v2 path instead of
+	// issuing through PKI. The secret is expected to carry "certificate"
+	// and "private_key" keys, plus an optional "ca_chain" PEM bundle.
+	KVPath string
+
+	// RefreshFraction is the fraction of the lease TTL at which the loader
+	// proactively re-issues the certificate. Defaults to 2/3.
+	RefreshFraction float64
+}
+
+func (c *VaultConfig) refreshFraction() float64 {
+	if c.RefreshFraction <= 0 || c.RefreshFraction >= 1 {
+		return 2.0 / 3.0
+	}
+	return c.RefreshFraction
+}
+
+// VaultCertSource holds the current server certificate sourced from Vault
+// and keeps it fresh in the background, swapping it atomically so that
+// tls.Config.GetCertificate callers never observe a half-updated cert.
+type VaultCertSource struct {
+	config *VaultConfig
+	client *vaultapi.Client
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	caPool *x509.CertPool
+
+	nextRefresh time.Duration
+	stop        chan struct{}
+}
+
+// NewVaultCertSource authenticates to Vault per cfg, fetches an initial
+// certificate and starts the background refresher. The returned source's
+// GetCertificate method is meant to be installed as tls.Config.GetCertificate.
+func NewVaultCertSource(cfg *VaultConfig) (*VaultCertSource, error) {
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: cfg.Address})
+	if err != nil {
+		return nil, fmt.Errorf("vault: new client: %s", err)
+	}
+
+	s := &VaultCertSource{
+		config: cfg,
+		client: client,
+		stop:   make(chan struct{}),
+	}
+
+	if err := s.login(); err != nil {
+		return nil, fmt.Errorf("vault: login: %s", err)
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, fmt.Errorf("vault: initial cert fetch: %s", err)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *VaultCertSource) login() error {
+	switch s.config.AuthMethod {
+	case VaultAuthToken:
+		s.client.SetToken(s.config.Token)
+		return nil
+	case VaultAuthAppRole:
+		secret, err := s.client.Logical().Write("auth/approle/login", map[string]interface{}{
+			"role_id":   s.config.AppRoleID,
+			"secret_id": s.config.AppSecretID,
+		})
+		if err != nil {
+			return err
+		}
+		s.client.SetToken(secret.Auth.ClientToken)
+		return nil
+	case VaultAuthKubernetes:
+		jwt, err := readFile(s.config.KubernetesJWTPath)
+		if err != nil {
+			return err
+		}
+		secret, err := s.client.Logical().Write("auth/kubernetes/login", map[string]interface{}{
+			"role": s.config.KubernetesRole,
+			"jwt":  jwt,
+		})
+		if err != nil {
+			return err
+		}
+		s.client.SetToken(secret.Auth.ClientToken)
+		return nil
+	default:
+		return fmt.Errorf("unsupported vault auth method %q", s.config.AuthMethod)
+	}
+}
+
+// reload issues (or reads) fresh certificate material from Vault, installs
+// it, and schedules the next refresh from the returned lease TTL.
+func (s *VaultCertSource) reload() error {
+	ttl, cert, caPool, err := s.fetch()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cert = cert
+	s.caPool = caPool
+	s.mu.Unlock()
+
+	s.scheduleNext(ttl)
+	return nil
+}
+
+func (s *VaultCertSource) fetch() (time.Duration, *tls.Certificate, *x509.CertPool, error) {
+	if s.config.KVPath != "" {
+		return s.fetchFromKV()
+	}
+	return s.fetchFromPKI()
+}
+
+func (s *VaultCertSource) fetchFromPKI() (time.Duration, *tls.Certificate, *x509.CertPool, error) {
+	path := fmt.Sprintf("%s/issue/%s", s.config.PKIMountPath, s.config.PKIRole)
+	secret, err := s.client.Logical().Write(path, map[string]interface{}{
+		"common_name": s.config.CommonName,
+	})
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	certPEM, _ := secret.Data["certificate"].(string)
+	keyPEM, _ := secret.Data["private_key"].(string)
+	leaseTTL := time.Duration(secret.LeaseDuration) * time.Second
+
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	caPool := x509.NewCertPool()
+	hasCA := false
+	if caChain, ok := secret.Data["ca_chain"].([]interface{}); ok {
+		for _, c := range caChain {
+			if pem, ok := c.(string); ok && caPool.AppendCertsFromPEM([]byte(pem)) {
+				hasCA = true
+			}
+		}
+	}
+	if issuingCA, ok := secret.Data["issuing_ca"].(string); ok && issuingCA != "" {
+		if caPool.AppendCertsFromPEM([]byte(issuingCA)) {
+			hasCA = true
+		}
+	}
+	if !hasCA {
+		caPool = nil
+	}
+
+	return leaseTTL, &cert, caPool, nil
+}
+
+func (s *VaultCertSource) fetchFromKV() (time.Duration, *tls.Certificate, *x509.CertPool, error) {
+	secret, err := s.client.Logical().Read(s.config.KVPath)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if secret == nil {
+		return 0, nil, nil, fmt.Errorf("no secret at %s", s.config.KVPath)
+	}
+
+	data, _ := secret.Data["data"].(map[string]interface{})
+	certPEM, _ := data["certificate"].(string)
+	keyPEM, _ := data["private_key"].(string)
+
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	var caPool *x509.CertPool
+	if caPEM, _ := data["ca_chain"].(string); caPEM != "" {
+		caPool = x509.NewCertPool()
+		caPool.AppendCertsFromPEM([]byte(caPEM))
+	}
+
+	// KV entries are static; refresh on a fixed cadence rather than a lease.
+	return 1 * time.Hour, &cert, caPool, nil
+}
+
+func (s *VaultCertSource) scheduleNext(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = 1 * time.Hour
+	}
+	s.nextRefresh = time.Duration(float64(ttl) * s.config.refreshFraction())
+}
+
+func (s *VaultCertSource) run() {
+	delay := s.nextRefresh
+	if delay <= 0 {
+		delay = 1 * time.Minute
+	}
+	for {
+		select {
+		case <-time.After(delay):
+			if err := s.reload(); err != nil {
+				glog.Warning("vault cert refresh failed, keeping current cert: ", err)
+				if cal.IsEnabled() {
+					cal.Event(cal.TxnTypeAccept, "vault", cal.StatusWarning, []byte("err=\"vault refresh: "+err.Error()+"\""))
+				}
+				delay = 1 * time.Minute
+				continue
+			}
+			delay = s.nextRefresh
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// ReloadNow forces an immediate certificate refresh, used by
+// SslListener.Refresh so operators can trigger reload outside the normal
+// cadence.
+func (s *VaultCertSource) ReloadNow() error {
+	return s.reload()
+}
+
+// GetCertificate implements the signature expected by tls.Config so the
+// server atomically swaps in the latest Vault-issued certificate without
+// disturbing existing connections.
+func (s *VaultCertSource) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cert == nil {
+		return nil, fmt.Errorf("vault: no certificate available")
+	}
+	return s.cert, nil
+}
+
+// CAPool returns the CA bundle sourced alongside the current certificate,
+// or nil if Vault didn't return one. It is replaced wholesale on every
+// refresh, so callers building a tls.Config.ClientCAs/RootCAs should call
+// this rather than caching the result.
+func (s *VaultCertSource) CAPool() *x509.CertPool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.caPool
+}
+
+func (s *VaultCertSource) Close() {
+	close(s.stop)
+}
+
+func readFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,154 @@
+package sec
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// selfSignedPEM generates a throwaway self-signed cert/key pair for tests
+// that need well-formed PEM material, rather than Vault's actual response.
+func selfSignedPEM(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %s", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "junodb-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %s", err)
+	}
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	return certPEM, keyPEM
+}
+
+func newTestVaultSource(t *testing.T, handler http.HandlerFunc) *VaultCertSource {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatalf("vaultapi.NewClient: %s", err)
+	}
+	return &VaultCertSource{config: &VaultConfig{}, client: client, stop: make(chan struct{})}
+}
+
+func TestFetchFromKVParsesCABundle(t *testing.T) {
+	certPEM, keyPEM := selfSignedPEM(t)
+	caPEM, _ := selfSignedPEM(t)
+
+	s := newTestVaultSource(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data": map[string]interface{}{
+					"certificate": certPEM,
+					"private_key": keyPEM,
+					"ca_chain":    caPEM,
+				},
+			},
+		})
+	})
+	s.config.KVPath = "secret/data/junodb/server-cert"
+
+	_, cert, caPool, err := s.fetchFromKV()
+	if err != nil {
+		t.Fatalf("fetchFromKV: %s", err)
+	}
+	if cert == nil {
+		t.Fatal("expected a certificate")
+	}
+	if caPool == nil {
+		t.Fatal("expected a CA pool to be parsed from ca_chain")
+	}
+}
+
+func TestFetchFromKVWithoutCABundle(t *testing.T) {
+	certPEM, keyPEM := selfSignedPEM(t)
+
+	s := newTestVaultSource(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data": map[string]interface{}{
+					"certificate": certPEM,
+					"private_key": keyPEM,
+				},
+			},
+		})
+	})
+	s.config.KVPath = "secret/data/junodb/server-cert"
+
+	_, _, caPool, err := s.fetchFromKV()
+	if err != nil {
+		t.Fatalf("fetchFromKV: %s", err)
+	}
+	if caPool != nil {
+		t.Fatalf("caPool = %v, want nil when Vault didn't return a ca_chain", caPool)
+	}
+}
+
+func TestServerCertSourceAccessors(t *testing.T) {
+	SetServerCertSource(nil)
+	if got := ServerCertSource(); got != nil {
+		t.Fatalf("ServerCertSource() = %v, want nil", got)
+	}
+
+	src := &VaultCertSource{}
+	SetServerCertSource(src)
+	defer SetServerCertSource(nil)
+	if got := ServerCertSource(); got != src {
+		t.Fatalf("ServerCertSource() = %v, want %v", got, src)
+	}
+}
+
+// TestServerCertSourceCAPoolReachableForTLSWiring exercises the path a TLS
+// listener uses to build tls.Config.ClientCAs from the installed source:
+// ServerCertSource().CAPool().
+func TestServerCertSourceCAPoolReachableForTLSWiring(t *testing.T) {
+	certPEM, keyPEM := selfSignedPEM(t)
+	caPEM, _ := selfSignedPEM(t)
+
+	s := newTestVaultSource(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data": map[string]interface{}{
+					"certificate": certPEM,
+					"private_key": keyPEM,
+					"ca_chain":    caPEM,
+				},
+			},
+		})
+	})
+	s.config.KVPath = "secret/data/junodb/server-cert"
+	if err := s.reload(); err != nil {
+		t.Fatalf("reload: %s", err)
+	}
+
+	SetServerCertSource(s)
+	defer SetServerCertSource(nil)
+
+	pool := ServerCertSource().CAPool()
+	if pool == nil {
+		t.Fatal("expected a non-nil CA pool installed alongside the certificate")
+	}
+}
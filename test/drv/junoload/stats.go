@@ -0,0 +1,358 @@
+//
+//  Copyright 2023 PayPal Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one or more
+//  contributor license agreements.  See the NOTICE file distributed with
+//  this work for additional information regarding copyright ownership.
+//  The ASF licenses this file to You under the Apache License, Version 2.0
+//  (the "License"); you may not use this file except in compliance with
+//  the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"juno/third_party/forked/golang/glog"
+)
+
+// MetricsSink receives per-request observations out of Statistics.Put, so a
+// load run can be scraped or aggregated externally instead of only being
+// inspected at end-of-run.
+type MetricsSink interface {
+	ObserveLatency(reqType RequestType, dur time.Duration)
+	IncError(reqType RequestType, errClass string)
+	SetInFlight(n int64)
+}
+
+// multiSink fans a single observation out to several sinks, so e.g. the
+// in-memory summary and a Prometheus exporter can run side by side.
+type multiSink struct {
+	sinks []MetricsSink
+}
+
+func newMultiSink(sinks ...MetricsSink) *multiSink {
+	return &multiSink{sinks: sinks}
+}
+
+func (m *multiSink) ObserveLatency(reqType RequestType, dur time.Duration) {
+	for _, s := range m.sinks {
+		s.ObserveLatency(reqType, dur)
+	}
+}
+
+func (m *multiSink) IncError(reqType RequestType, errClass string) {
+	for _, s := range m.sinks {
+		s.IncError(reqType, errClass)
+	}
+}
+
+func (m *multiSink) SetInFlight(n int64) {
+	for _, s := range m.sinks {
+		s.SetInFlight(n)
+	}
+}
+
+// errClassOf maps err into a small, fixed set of classes suitable as a
+// Prometheus label value. Using err.Error() directly would let a single
+// label explode into one time series per distinct error message (e.g. one
+// per failed key), which is exactly the unbounded-cardinality pattern
+// Prometheus warns against.
+func errClassOf(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, io.EOF) {
+		return "connection"
+	}
+
+	return "other"
+}
+
+// Statistics is the in-memory summary of a test run, kept for the existing
+// end-of-run report. It also fans out every observation to an optional
+// MetricsSink so long-running load runs can be scraped while in progress.
+type Statistics struct {
+	tmStart time.Time
+
+	mu        sync.Mutex
+	numReq    int64
+	numErrors int64
+	totalDur  [kNumRequestTypes]time.Duration
+	counts    [kNumRequestTypes]int64
+	errCounts [kNumRequestTypes]int64
+
+	inFlight int64
+	sink     MetricsSink
+}
+
+func NewStatistics(sink MetricsSink) *Statistics {
+	return &Statistics{
+		tmStart: time.Now(),
+		sink:    sink,
+	}
+}
+
+func (s *Statistics) Put(reqType RequestType, dur time.Duration, err error) {
+	s.mu.Lock()
+	s.numReq++
+	s.counts[reqType]++
+	s.totalDur[reqType] += dur
+	if err != nil {
+		s.numErrors++
+		s.errCounts[reqType]++
+	}
+	s.mu.Unlock()
+
+	if s.sink != nil {
+		s.sink.ObserveLatency(reqType, dur)
+		if err != nil {
+			s.sink.IncError(reqType, errClassOf(err))
+		}
+	}
+}
+
+func (s *Statistics) SetInFlight(n int64) {
+	atomic.StoreInt64(&s.inFlight, n)
+	if s.sink != nil {
+		s.sink.SetInFlight(n)
+	}
+}
+
+func (s *Statistics) GetNumRequests() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.numReq
+}
+
+// Reset clears the accumulated counters and restarts tmStart, turning
+// Statistics into a time-bucketed summary when used as the "moving" window
+// rather than the lifetime total.
+func (s *Statistics) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tmStart = time.Now()
+	s.numReq = 0
+	s.numErrors = 0
+	for i := range s.counts {
+		s.counts[i] = 0
+		s.errCounts[i] = 0
+		s.totalDur[i] = 0
+	}
+}
+
+// InMemorySink mirrors the bookkeeping Statistics already does, packaged as
+// a MetricsSink so it can be combined with the other sinks through
+// multiSink.
+type InMemorySink struct {
+	mu        sync.Mutex
+	counts    [kNumRequestTypes]int64
+	errCounts map[string]int64
+	inFlight  int64
+}
+
+func NewInMemorySink() *InMemorySink {
+	return &InMemorySink{errCounts: make(map[string]int64)}
+}
+
+func (s *InMemorySink) ObserveLatency(reqType RequestType, dur time.Duration) {
+	s.mu.Lock()
+	s.counts[reqType]++
+	s.mu.Unlock()
+}
+
+func (s *InMemorySink) IncError(reqType RequestType, errClass string) {
+	s.mu.Lock()
+	s.errCounts[errClass]++
+	s.mu.Unlock()
+}
+
+func (s *InMemorySink) SetInFlight(n int64) {
+	atomic.StoreInt64(&s.inFlight, n)
+}
+
+// GoMetricsSink aggregates observations with go-metrics counters, gauges and
+// a reservoir-sampled histogram, logging a summary on a fixed interval so a
+// run can be watched without a scraper attached.
+type GoMetricsSink struct {
+	registry  metrics.Registry
+	latencies [kNumRequestTypes]metrics.Histogram
+	errors    [kNumRequestTypes]metrics.Counter
+	inFlight  metrics.Gauge
+}
+
+func NewGoMetricsSink(reportInterval time.Duration) *GoMetricsSink {
+	s := &GoMetricsSink{registry: metrics.NewRegistry()}
+
+	for i := RequestType(0); i < kNumRequestTypes; i++ {
+		sample := metrics.NewUniformSample(1028)
+		s.latencies[i] = metrics.NewHistogram(sample)
+		s.errors[i] = metrics.NewCounter()
+		s.registry.Register(i.String()+".latency", s.latencies[i])
+		s.registry.Register(i.String()+".errors", s.errors[i])
+	}
+	s.inFlight = metrics.NewGauge()
+	s.registry.Register("inflight", s.inFlight)
+
+	if reportInterval > 0 {
+		go s.reportPeriodically(reportInterval)
+	}
+	return s
+}
+
+func (s *GoMetricsSink) reportPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.registry.Each(func(name string, i interface{}) {
+			if h, ok := i.(metrics.Histogram); ok {
+				glog.Infof("%s: count=%d mean=%.1f p99=%.1f", name, h.Count(), h.Mean(), h.Percentile(0.99))
+			}
+		})
+	}
+}
+
+func (s *GoMetricsSink) ObserveLatency(reqType RequestType, dur time.Duration) {
+	s.latencies[reqType].Update(dur.Microseconds())
+}
+
+func (s *GoMetricsSink) IncError(reqType RequestType, errClass string) {
+	s.errors[reqType].Inc(1)
+}
+
+func (s *GoMetricsSink) SetInFlight(n int64) {
+	s.inFlight.Update(n)
+}
+
+// PrometheusSink exposes juno_client_request_latency_seconds as a histogram
+// plus request/error counters on -metrics-addr, so long-running load runs
+// can be scraped from Grafana instead of only inspected at end-of-run.
+type PrometheusSink struct {
+	latency  *prometheus.HistogramVec
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	inFlight prometheus.Gauge
+}
+
+func NewPrometheusSink(metricsAddr string) *PrometheusSink {
+	s := &PrometheusSink{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "juno_client_request_latency_seconds",
+			Help:    "junoload request latency in seconds, by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"op"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "juno_client_requests_total",
+			Help: "Total junoload requests, by operation.",
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "juno_client_request_errors_total",
+			Help: "Total junoload request errors, by operation and error class.",
+		}, []string{"op", "err_class"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "juno_client_requests_in_flight",
+			Help: "Requests currently in flight.",
+		}),
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(s.latency, s.requests, s.errors, s.inFlight)
+
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+				glog.Error("metrics server failed: ", err)
+			}
+		}()
+	}
+	return s
+}
+
+func (s *PrometheusSink) ObserveLatency(reqType RequestType, dur time.Duration) {
+	op := reqType.String()
+	s.latency.WithLabelValues(op).Observe(dur.Seconds())
+	s.requests.WithLabelValues(op).Inc()
+}
+
+func (s *PrometheusSink) IncError(reqType RequestType, errClass string) {
+	s.errors.WithLabelValues(reqType.String(), errClass).Inc()
+}
+
+func (s *PrometheusSink) SetInFlight(n int64) {
+	s.inFlight.Set(float64(n))
+}
+
+// MetricsConfig selects which MetricsSink(s) a junoload run reports
+// through, parsed from CLI flags by main and applied via
+// NewConfiguredStatistics.
+type MetricsConfig struct {
+	// MetricsAddr, when non-empty, serves Prometheus metrics on this
+	// address's /metrics endpoint (e.g. ":9090").
+	MetricsAddr string
+
+	// GoMetricsLogInterval, when positive, logs a go-metrics summary on
+	// this interval alongside whatever other sinks are configured.
+	GoMetricsLogInterval time.Duration
+}
+
+// RegisterMetricsFlags registers junoload's metrics flags on fs and returns
+// the config they populate once fs.Parse has run.
+func RegisterMetricsFlags(fs *flag.FlagSet) *MetricsConfig {
+	cfg := &MetricsConfig{}
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "address to serve Prometheus /metrics on, e.g. :9090 (disabled if empty)")
+	fs.DurationVar(&cfg.GoMetricsLogInterval, "metrics-log-interval", 0, "interval to log a go-metrics summary at (disabled if zero)")
+	return cfg
+}
+
+// NewConfiguredStatistics builds a Statistics backed by an InMemorySink
+// (for the existing end-of-run report) plus whichever of PrometheusSink and
+// GoMetricsSink cfg enables.
+func NewConfiguredStatistics(cfg *MetricsConfig) *Statistics {
+	sinks := []MetricsSink{NewInMemorySink()}
+	if cfg != nil {
+		if cfg.MetricsAddr != "" {
+			sinks = append(sinks, NewPrometheusSink(cfg.MetricsAddr))
+		}
+		if cfg.GoMetricsLogInterval > 0 {
+			sinks = append(sinks, NewGoMetricsSink(cfg.GoMetricsLogInterval))
+		}
+	}
+	return NewStatistics(newMultiSink(sinks...))
+}
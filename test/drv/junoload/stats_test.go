@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRegisterMetricsFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := RegisterMetricsFlags(fs)
+
+	if err := fs.Parse([]string{"-metrics-addr", ":9090", "-metrics-log-interval", "5s"}); err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+	if cfg.MetricsAddr != ":9090" {
+		t.Fatalf("MetricsAddr = %q, want %q", cfg.MetricsAddr, ":9090")
+	}
+	if cfg.GoMetricsLogInterval != 5*time.Second {
+		t.Fatalf("GoMetricsLogInterval = %s, want 5s", cfg.GoMetricsLogInterval)
+	}
+}
+
+func TestNewConfiguredStatisticsDefaultsToInMemoryOnly(t *testing.T) {
+	stats := NewConfiguredStatistics(&MetricsConfig{})
+	stats.Put(kRequestTypeGet, time.Millisecond, nil)
+	if got := stats.GetNumRequests(); got != 1 {
+		t.Fatalf("GetNumRequests() = %d, want 1", got)
+	}
+}
+
+type fakeTimeoutErr struct{}
+
+func (fakeTimeoutErr) Error() string   { return "dial tcp 10.0.0.1:8080: i/o timeout" }
+func (fakeTimeoutErr) Timeout() bool   { return true }
+func (fakeTimeoutErr) Temporary() bool { return true }
+
+func TestErrClassOfFixedClasses(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"net timeout", fakeTimeoutErr{}, "timeout"},
+		{"context deadline exceeded", context.DeadlineExceeded, "timeout"},
+		{"context canceled", context.Canceled, "canceled"},
+		{"op error", &net.OpError{Op: "read", Err: errors.New("connection reset")}, "connection"},
+		{"eof", io.EOF, "connection"},
+		{"unrecognized", errors.New("some key-specific message"), "other"},
+	}
+	for _, c := range cases {
+		if got := errClassOf(c.err); got != c.want {
+			t.Errorf("%s: errClassOf() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestErrClassOfBoundedCardinality(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		seen[errClassOf(errors.New("distinct message"))] = true
+	}
+	if len(seen) != 1 {
+		t.Fatalf("100 distinct error messages produced %d classes, want 1", len(seen))
+	}
+}
+
+func TestNewConfiguredStatisticsAddsGoMetricsSink(t *testing.T) {
+	stats := NewConfiguredStatistics(&MetricsConfig{GoMetricsLogInterval: time.Hour})
+	stats.Put(kRequestTypeGet, time.Millisecond, nil)
+	if got := stats.GetNumRequests(); got != 1 {
+		t.Fatalf("GetNumRequests() = %d, want 1", got)
+	}
+}
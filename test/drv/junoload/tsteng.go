@@ -22,7 +22,6 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
-	"math"
 	"math/rand"
 	"sync"
 	"time"
@@ -61,6 +60,10 @@ type (
 		offsetDel  int
 		offsetGet  int
 		LastDelete bool
+
+		// keyDist selects the offset into the preloaded keyspace for Get;
+		// defaults to UniformKeyDistribution when unset.
+		keyDist KeyDistribution
 	}
 
 	TestEngine struct {
@@ -73,6 +76,15 @@ type (
 		stats           *Statistics
 		movingStats     *Statistics
 		numReqPerSecond int
+
+		// mix, when set, makes Run draw each operation's RequestType from a
+		// YCSB-style proportion spec instead of the scripted
+		// reqSequence.items loop.
+		mix *MixSpec
+
+		// valueSizeDist, when set, makes payload draw the next payload's
+		// size from it instead of rdgen's own fixed/uniform sizing.
+		valueSizeDist ValueSizeDistribution
 	}
 	InvokeFunc func() error
 )
@@ -94,13 +106,6 @@ func NewRandomKey(s int) []byte {
 	return key
 }
 
-func expRand(n int) int {
-	len := float64(n + 1)
-	m := float64(rand.Intn(n) + 1)
-	x := n - 1 - int(float64(n)*math.Log(m)/math.Log(len))
-	return x
-}
-
 func (t RequestType) String() (str string) {
 	switch t {
 	case kRequestTypeCreate:
@@ -169,7 +174,10 @@ func (s *RecordStore) getRecord() (rec Record, err error) {
 		if s.numKeys >= MaxDeletes {
 			count = s.numKeys >> 2
 		}
-		k := expRand(count)
+		if s.keyDist == nil {
+			s.keyDist = UniformKeyDistribution{}
+		}
+		k := s.keyDist.Next(count)
 		s.currGet = k
 
 		rec = Record{
@@ -226,6 +234,45 @@ func (s *RecordStore) Take() (rec Record, err error) {
 	return
 }
 
+// Apply configures e's key distribution, value-size distribution and
+// run-phase mix from cfg. numKeys is the preloaded keyspace size; pass 0 for
+// the legacy non-preloaded path, where no key distribution applies.
+func (e *TestEngine) Apply(cfg *WorkloadConfig, numKeys int) error {
+	if numKeys > 0 {
+		dist, err := NewKeyDistribution(cfg, numKeys)
+		if err != nil {
+			return err
+		}
+		e.recStore.keyDist = dist
+	}
+
+	valueSizeDist, err := NewValueSizeDistribution(cfg)
+	if err != nil {
+		return err
+	}
+	e.valueSizeDist = valueSizeDist
+
+	mix, err := NewMixSpecFromString(cfg.Mix)
+	if err != nil {
+		return err
+	}
+	e.mix = mix
+
+	return nil
+}
+
+// payload returns the next request payload: a buffer sized by
+// valueSizeDist when one is configured, filled with random bytes, or
+// rdgen's own fixed/uniform sizing otherwise.
+func (e *TestEngine) payload() []byte {
+	if e.valueSizeDist == nil {
+		return e.rdgen.createPayload()
+	}
+	b := make([]byte, e.valueSizeDist.NextSize())
+	rand.Read(b)
+	return b
+}
+
 func (e *TestEngine) Init() {
 	e.invokeFuncs = make([]InvokeFunc, kNumRequestTypes)
 	e.invokeFuncs[kRequestTypeCreate] = e.invokeCreate
@@ -249,7 +296,7 @@ func (e *TestEngine) restoreData() {
 		now := time.Now()
 
 		key := NewRandomKey(e.recStore.offsetDel + i)
-		_, err := e.client.Create(key, e.rdgen.createPayload())
+		_, err := e.client.Create(key, e.payload())
 		tm := time.Since(now)
 
 		e.stats.Put(kRequestTypeCreate, tm, err)
@@ -261,8 +308,31 @@ func (e *TestEngine) restoreData() {
 	}
 }
 
+// RunLoad implements YCSB's "load" phase: sequentially insert numKeys
+// records ahead of a mixed run phase driven by a MixSpec. When the record
+// store uses preloaded keys (recStore.numKeys > 0), invokeCreate inserts
+// nothing, so RunLoad writes no records, though each skipped create is
+// still counted in stats.
+func (e *TestEngine) RunLoad(numKeys int) {
+	for i := 0; i < numKeys; i++ {
+		now := time.Now()
+		err := e.invokeCreate()
+		tm := time.Since(now)
+		e.stats.Put(kRequestTypeCreate, tm, err)
+		e.movingStats.Put(kRequestTypeCreate, tm, err)
+		if err != nil {
+			glog.Errorf("load: %s error: %s", kRequestTypeCreate.String(), err)
+		}
+	}
+}
+
 func (e *TestEngine) Run(wg *sync.WaitGroup, chDone <-chan bool) {
 	defer wg.Done()
+	if e.mix != nil {
+		e.runMix(chDone)
+		return
+	}
+
 	startTime := time.Now()
 	var numreq int = 0
 	errCount := 0
@@ -319,6 +389,48 @@ func (e *TestEngine) Run(wg *sync.WaitGroup, chDone <-chan bool) {
 	}
 }
 
+// runMix implements YCSB's "run" phase: mixed operations against the
+// already-populated key space, with each operation's type drawn from
+// e.mix instead of a scripted sequence.
+func (e *TestEngine) runMix(chDone <-chan bool) {
+	startTime := time.Now()
+	var numreq int = 0
+	errCount := 0
+	for {
+		select {
+		case <-chDone:
+			e.restoreData()
+			return
+		default:
+			reqType := e.mix.Next()
+			now := time.Now()
+			err := e.invoke(reqType)
+			tm := time.Since(now)
+			e.stats.Put(reqType, tm, err)
+			e.movingStats.Put(reqType, tm, err)
+			if err != nil {
+				glog.Errorf("%s error: %s", reqType.String(), err)
+				errCount++
+				if errCount > 100 {
+					return
+				}
+			}
+			diff := now.Sub(startTime)
+			if e.rdgen.isVariable && diff > (12*time.Second) {
+				e.numReqPerSecond = e.rdgen.getThroughPut()
+				startTime = time.Now()
+				numreq = 0
+			}
+			numreq++
+			if e.rdgen.isVariable {
+				e.checkSpeedForVariableTp(now, numreq, startTime)
+			} else {
+				e.checkSpeedDelayIfNeeded(now)
+			}
+		}
+	}
+}
+
 func (e *TestEngine) checkSpeedDelayIfNeeded(now time.Time) {
 	num := e.stats.GetNumRequests()
 	if num < 10 {
@@ -361,7 +473,7 @@ func (e *TestEngine) invokeCreate() (err error) {
 	key := newTestKey()
 	var ctx client.IContext
 
-	if ctx, err = e.client.Create(key, e.rdgen.createPayload(), client.WithTTL(e.rdgen.getTTL())); err == nil {
+	if ctx, err = e.client.Create(key, e.payload(), client.WithTTL(e.rdgen.getTTL())); err == nil {
 		rec := Record{
 			key: key,
 			ctx: ctx,
@@ -388,7 +500,7 @@ func (e *TestEngine) invokeUpdate() (err error) {
 	var rec Record
 
 	if rec, err = e.recStore.Get(); err == nil {
-		_, err = e.client.Update(rec.key, e.rdgen.createPayload(), client.WithTTL(e.rdgen.getTTL()))
+		_, err = e.client.Update(rec.key, e.payload(), client.WithTTL(e.rdgen.getTTL()))
 	}
 	return
 }
@@ -397,7 +509,7 @@ func (e *TestEngine) invokeSet() (err error) {
 	var rec Record
 
 	if rec, err = e.recStore.Get(); err == nil {
-		_, err = e.client.Set(rec.key, e.rdgen.createPayload(), client.WithTTL(e.rdgen.getTTL()))
+		_, err = e.client.Set(rec.key, e.payload(), client.WithTTL(e.rdgen.getTTL()))
 	}
 	return
 }
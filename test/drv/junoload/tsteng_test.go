@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestTestEngineApplyWiresValueSizeDistribution(t *testing.T) {
+	e := &TestEngine{}
+	cfg := &WorkloadConfig{ValueSizeDist: "constant", ValueSizeMin: 16}
+	if err := e.Apply(cfg, 0); err != nil {
+		t.Fatalf("Apply: %s", err)
+	}
+
+	b := e.payload()
+	if len(b) != 16 {
+		t.Fatalf("payload() length = %d, want 16", len(b))
+	}
+}
+
+func TestTestEngineApplyWiresKeyDistribution(t *testing.T) {
+	e := &TestEngine{}
+	cfg := &WorkloadConfig{KeyDist: "zipfian", KeyDistTheta: 0.99}
+	if err := e.Apply(cfg, 100); err != nil {
+		t.Fatalf("Apply: %s", err)
+	}
+	if _, ok := e.recStore.keyDist.(*ZipfianKeyDistribution); !ok {
+		t.Fatalf("keyDist = %T, want *ZipfianKeyDistribution", e.recStore.keyDist)
+	}
+}
+
+func TestTestEngineApplyWiresMix(t *testing.T) {
+	e := &TestEngine{}
+	cfg := &WorkloadConfig{Mix: "get=1.0"}
+	if err := e.Apply(cfg, 0); err != nil {
+		t.Fatalf("Apply: %s", err)
+	}
+	if e.mix == nil {
+		t.Fatal("expected Apply to install a MixSpec from a non-empty Mix string")
+	}
+}
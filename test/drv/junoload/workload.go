@@ -0,0 +1,393 @@
+//
+//  Copyright 2023 PayPal Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one or more
+//  contributor license agreements.  See the NOTICE file distributed with
+//  this work for additional information regarding copyright ownership.
+//  The ASF licenses this file to You under the Apache License, Version 2.0
+//  (the "License"); you may not use this file except in compliance with
+//  the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// KeyDistribution picks the next key offset out of a keyspace of the given
+// size, mirroring the workload families YCSB popularized. It replaces the
+// ad-hoc expRand/NewRandomKey selection previously hard-coded into
+// RecordStore.
+type KeyDistribution interface {
+	// Next returns an offset in [0, numKeys).
+	Next(numKeys int) int
+}
+
+// UniformKeyDistribution picks each key with equal probability.
+type UniformKeyDistribution struct{}
+
+func (UniformKeyDistribution) Next(numKeys int) int {
+	return rand.Intn(numKeys)
+}
+
+// ZipfianKeyDistribution picks keys with a Zipfian skew (a small set of keys
+// receive most of the traffic). It uses the rejection-based generator from
+// Gray, Sedgewick and Flajolet (as used by YCSB's ZipfianGenerator) so it
+// remains accurate for keyspaces in the billions without precomputing a CDF.
+type ZipfianKeyDistribution struct {
+	theta      float64
+	zeta2Theta float64
+	alpha      float64
+
+	// n, zetaN and eta are cached for the keyspace size they were last
+	// computed for; Next rebuilds them whenever the caller passes a
+	// different numKeys, since a zetaN/eta pair computed for one keyspace
+	// size skews the distribution for any other.
+	n     int
+	zetaN float64
+	eta   float64
+}
+
+func NewZipfianKeyDistribution(numKeys int, theta float64) *ZipfianKeyDistribution {
+	z := &ZipfianKeyDistribution{theta: theta}
+	z.zeta2Theta = zeta(2, theta)
+	z.alpha = 1.0 / (1.0 - theta)
+	z.rebuild(numKeys)
+	return z
+}
+
+func zeta(n int, theta float64) float64 {
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		sum += 1.0 / math.Pow(float64(i+1), theta)
+	}
+	return sum
+}
+
+// rebuild recomputes the cached zetaN/eta for a keyspace of size numKeys.
+func (z *ZipfianKeyDistribution) rebuild(numKeys int) {
+	z.n = numKeys
+	z.zetaN = zeta(numKeys, z.theta)
+	z.eta = (1 - math.Pow(2.0/float64(numKeys), 1-z.theta)) / (1 - z.zeta2Theta/z.zetaN)
+}
+
+func (z *ZipfianKeyDistribution) Next(numKeys int) int {
+	if numKeys != z.n {
+		z.rebuild(numKeys)
+	}
+
+	u := rand.Float64()
+	uz := u * z.zetaN
+
+	if uz < 1.0 {
+		return 0
+	}
+	if uz < 1.0+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	k := int(float64(z.n) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if k >= numKeys {
+		k = numKeys - 1
+	}
+	return k
+}
+
+// LatestKeyDistribution favors recently inserted keys, modeled as a Zipfian
+// distribution over "how far back from the most recent key" rather than
+// over absolute offsets.
+type LatestKeyDistribution struct {
+	zipf *ZipfianKeyDistribution
+}
+
+func NewLatestKeyDistribution(numKeys int, theta float64) *LatestKeyDistribution {
+	return &LatestKeyDistribution{zipf: NewZipfianKeyDistribution(numKeys, theta)}
+}
+
+func (z *LatestKeyDistribution) Next(numKeys int) int {
+	back := z.zipf.Next(numKeys)
+	k := numKeys - 1 - back
+	if k < 0 {
+		k = 0
+	}
+	return k
+}
+
+// HotspotKeyDistribution sends HotOpFraction of operations to the first
+// HotDataFraction of the keyspace, and the rest uniformly over the
+// remainder.
+type HotspotKeyDistribution struct {
+	HotDataFraction float64
+	HotOpFraction   float64
+}
+
+func (h HotspotKeyDistribution) Next(numKeys int) int {
+	hotKeys := int(float64(numKeys) * h.HotDataFraction)
+	if hotKeys <= 0 {
+		hotKeys = 1
+	}
+	if rand.Float64() < h.HotOpFraction {
+		return rand.Intn(hotKeys)
+	}
+	if numKeys-hotKeys <= 0 {
+		return rand.Intn(numKeys)
+	}
+	return hotKeys + rand.Intn(numKeys-hotKeys)
+}
+
+// ValueSizeDistribution picks the size in bytes of the next payload, so
+// RandomGen.createPayload no longer has to assume a single fixed or
+// uniform size.
+type ValueSizeDistribution interface {
+	NextSize() int
+}
+
+type ConstantValueSize int
+
+func (c ConstantValueSize) NextSize() int { return int(c) }
+
+type UniformValueSize struct {
+	Min, Max int
+}
+
+func (u UniformValueSize) NextSize() int {
+	if u.Max <= u.Min {
+		return u.Min
+	}
+	return u.Min + rand.Intn(u.Max-u.Min+1)
+}
+
+type ZipfianValueSize struct {
+	dist     *ZipfianKeyDistribution
+	min, max int
+}
+
+func NewZipfianValueSize(min, max int, theta float64) *ZipfianValueSize {
+	return &ZipfianValueSize{dist: NewZipfianKeyDistribution(max-min+1, theta), min: min, max: max}
+}
+
+func (z *ZipfianValueSize) NextSize() int {
+	return z.min + z.dist.Next(z.max-z.min+1)
+}
+
+// HistogramValueSize draws sizes from an empirical histogram loaded from a
+// file of "size count" lines, the format YCSB's HistogramGenerator uses.
+type HistogramValueSize struct {
+	sizes    []int
+	weights  []int
+	total    int
+}
+
+func LoadHistogramValueSize(path string) (*HistogramValueSize, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &HistogramValueSize{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		size, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("histogram: bad size %q", fields[0])
+		}
+		count, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("histogram: bad count %q", fields[1])
+		}
+		h.sizes = append(h.sizes, size)
+		h.weights = append(h.weights, count)
+		h.total += count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if h.total == 0 {
+		return nil, fmt.Errorf("histogram: empty distribution in %s", path)
+	}
+	return h, nil
+}
+
+func (h *HistogramValueSize) NextSize() int {
+	r := rand.Intn(h.total)
+	for i, w := range h.weights {
+		if r < w {
+			return h.sizes[i]
+		}
+		r -= w
+	}
+	return h.sizes[len(h.sizes)-1]
+}
+
+// MixSpec declares the proportion of each request type in a run phase, e.g.
+// {get: 0.95, update: 0.05}, replacing the scripted reqSequence.items loop.
+type MixSpec struct {
+	proportions map[RequestType]float64
+	total       float64
+}
+
+func NewMixSpec(proportions map[RequestType]float64) *MixSpec {
+	m := &MixSpec{proportions: proportions}
+	for _, p := range proportions {
+		m.total += p
+	}
+	return m
+}
+
+// Next draws a RequestType according to the configured proportions.
+func (m *MixSpec) Next() RequestType {
+	r := rand.Float64() * m.total
+	for reqType, p := range m.proportions {
+		if r < p {
+			return reqType
+		}
+		r -= p
+	}
+	// Fall through for floating point edge cases: return any configured type.
+	for reqType := range m.proportions {
+		return reqType
+	}
+	return kRequestTypeGet
+}
+
+// WorkloadConfig selects the key and value-size distributions, and the
+// YCSB-style run-phase mix, parsed from CLI flags by main and applied to a
+// TestEngine via TestEngine.Apply.
+type WorkloadConfig struct {
+	// KeyDist is one of "uniform" (default), "zipfian", "latest", "hotspot".
+	KeyDist         string
+	KeyDistTheta    float64
+	HotDataFraction float64
+	HotOpFraction   float64
+
+	// ValueSizeDist is one of "" (default: use the caller's own sizing),
+	// "constant", "uniform", "zipfian", "histogram".
+	ValueSizeDist      string
+	ValueSizeMin       int
+	ValueSizeMax       int
+	ValueSizeTheta     float64
+	ValueSizeHistogram string
+
+	// Mix is a "reqtype=proportion,..." spec, e.g. "get=0.95,update=0.05".
+	// Empty keeps the scripted reqSequence run phase.
+	Mix string
+}
+
+// RegisterWorkloadFlags registers junoload's distribution flags on fs and
+// returns the config they populate once fs.Parse has run.
+func RegisterWorkloadFlags(fs *flag.FlagSet) *WorkloadConfig {
+	cfg := &WorkloadConfig{}
+	fs.StringVar(&cfg.KeyDist, "key-dist", "uniform", "key distribution for Get/Update/Set: uniform, zipfian, latest, hotspot")
+	fs.Float64Var(&cfg.KeyDistTheta, "key-dist-theta", 0.99, "skew parameter for the zipfian/latest key distributions")
+	fs.Float64Var(&cfg.HotDataFraction, "key-dist-hot-data-fraction", 0.1, "fraction of the keyspace treated as hot by the hotspot key distribution")
+	fs.Float64Var(&cfg.HotOpFraction, "key-dist-hot-op-fraction", 0.9, "fraction of operations directed at the hot keyspace by the hotspot key distribution")
+
+	fs.StringVar(&cfg.ValueSizeDist, "value-size-dist", "", `value size distribution: "" (use the caller's default sizing), constant, uniform, zipfian, histogram`)
+	fs.IntVar(&cfg.ValueSizeMin, "value-size-min", 0, "minimum payload size in bytes for the constant/uniform/zipfian value size distributions")
+	fs.IntVar(&cfg.ValueSizeMax, "value-size-max", 0, "maximum payload size in bytes for the uniform/zipfian value size distributions")
+	fs.Float64Var(&cfg.ValueSizeTheta, "value-size-theta", 0.99, "skew parameter for the zipfian value size distribution")
+	fs.StringVar(&cfg.ValueSizeHistogram, "value-size-histogram", "", `path to a "size count" histogram file for the histogram value size distribution`)
+
+	fs.StringVar(&cfg.Mix, "mix", "", `YCSB-style run-phase mix, e.g. "get=0.95,update=0.05" (empty keeps the scripted request sequence)`)
+	return cfg
+}
+
+// NewKeyDistribution builds the KeyDistribution cfg.KeyDist selects for a
+// keyspace of size numKeys.
+func NewKeyDistribution(cfg *WorkloadConfig, numKeys int) (KeyDistribution, error) {
+	switch cfg.KeyDist {
+	case "", "uniform":
+		return UniformKeyDistribution{}, nil
+	case "zipfian":
+		return NewZipfianKeyDistribution(numKeys, cfg.KeyDistTheta), nil
+	case "latest":
+		return NewLatestKeyDistribution(numKeys, cfg.KeyDistTheta), nil
+	case "hotspot":
+		return HotspotKeyDistribution{HotDataFraction: cfg.HotDataFraction, HotOpFraction: cfg.HotOpFraction}, nil
+	default:
+		return nil, fmt.Errorf("unknown key distribution %q", cfg.KeyDist)
+	}
+}
+
+// NewValueSizeDistribution builds the ValueSizeDistribution cfg.ValueSizeDist
+// selects, or nil if none was configured.
+func NewValueSizeDistribution(cfg *WorkloadConfig) (ValueSizeDistribution, error) {
+	switch cfg.ValueSizeDist {
+	case "":
+		return nil, nil
+	case "constant":
+		return ConstantValueSize(cfg.ValueSizeMin), nil
+	case "uniform":
+		return UniformValueSize{Min: cfg.ValueSizeMin, Max: cfg.ValueSizeMax}, nil
+	case "zipfian":
+		return NewZipfianValueSize(cfg.ValueSizeMin, cfg.ValueSizeMax, cfg.ValueSizeTheta), nil
+	case "histogram":
+		return LoadHistogramValueSize(cfg.ValueSizeHistogram)
+	default:
+		return nil, fmt.Errorf("unknown value size distribution %q", cfg.ValueSizeDist)
+	}
+}
+
+// NewMixSpecFromString parses a "reqtype=proportion,..." mix spec, or
+// returns a nil MixSpec if spec is empty so the caller keeps its scripted
+// reqSequence.
+func NewMixSpecFromString(spec string) (*MixSpec, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	proportions := make(map[RequestType]float64)
+	for _, part := range strings.Split(spec, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("mix: bad entry %q, want reqtype=proportion", part)
+		}
+		reqType, err := parseRequestType(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("mix: bad proportion %q: %s", kv[1], err)
+		}
+		proportions[reqType] = p
+	}
+	return NewMixSpec(proportions), nil
+}
+
+func parseRequestType(s string) (RequestType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "create":
+		return kRequestTypeCreate, nil
+	case "get":
+		return kRequestTypeGet, nil
+	case "update":
+		return kRequestTypeUpdate, nil
+	case "set":
+		return kRequestTypeSet, nil
+	case "destroy":
+		return kRequestTypeDestroy, nil
+	default:
+		return 0, fmt.Errorf("unknown request type %q", s)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"testing"
+)
+
+func TestZetaMatchesDirectSum(t *testing.T) {
+	got := zeta(5, 0.99)
+	want := 0.0
+	for i := 0; i < 5; i++ {
+		want += 1.0 / math.Pow(float64(i+1), 0.99)
+	}
+	if math.Abs(got-want) > 1e-9 {
+		t.Fatalf("zeta(5, 0.99) = %v, want %v", got, want)
+	}
+}
+
+func TestZipfianKeyDistributionRebuildsOnKeyspaceChange(t *testing.T) {
+	z := NewZipfianKeyDistribution(100, 0.99)
+	if z.n != 100 {
+		t.Fatalf("n = %d, want 100", z.n)
+	}
+	zetaAt100 := z.zetaN
+
+	k := z.Next(50)
+	if z.n != 50 {
+		t.Fatalf("n after Next(50) = %d, want 50", z.n)
+	}
+	if z.zetaN == zetaAt100 {
+		t.Fatal("zetaN was not recomputed for the new keyspace size")
+	}
+	if k < 0 || k >= 50 {
+		t.Fatalf("Next(50) = %d, want in [0, 50)", k)
+	}
+}
+
+func TestZipfianKeyDistributionStaysInRange(t *testing.T) {
+	z := NewZipfianKeyDistribution(1000, 0.99)
+	for i := 0; i < 1000; i++ {
+		k := z.Next(1000)
+		if k < 0 || k >= 1000 {
+			t.Fatalf("Next() = %d, want in [0, 1000)", k)
+		}
+	}
+}
+
+func TestMixSpecNextRespectsProportions(t *testing.T) {
+	m := NewMixSpec(map[RequestType]float64{
+		kRequestTypeGet: 1.0,
+	})
+	for i := 0; i < 20; i++ {
+		if got := m.Next(); got != kRequestTypeGet {
+			t.Fatalf("Next() = %v, want Get for a single-entry mix", got)
+		}
+	}
+}
+
+func TestNewMixSpecFromString(t *testing.T) {
+	m, err := NewMixSpecFromString("get=0.95,update=0.05")
+	if err != nil {
+		t.Fatalf("NewMixSpecFromString: %s", err)
+	}
+	if m == nil {
+		t.Fatal("expected a non-nil MixSpec for a non-empty spec")
+	}
+	if m.proportions[kRequestTypeGet] != 0.95 {
+		t.Fatalf("get proportion = %v, want 0.95", m.proportions[kRequestTypeGet])
+	}
+}
+
+func TestNewMixSpecFromStringEmpty(t *testing.T) {
+	m, err := NewMixSpecFromString("")
+	if err != nil {
+		t.Fatalf("NewMixSpecFromString: %s", err)
+	}
+	if m != nil {
+		t.Fatal("expected a nil MixSpec for an empty spec")
+	}
+}
+
+func TestNewMixSpecFromStringBadEntry(t *testing.T) {
+	if _, err := NewMixSpecFromString("bogus"); err == nil {
+		t.Fatal("expected an error for an entry without '='")
+	}
+	if _, err := NewMixSpecFromString("frobnicate=0.5"); err == nil {
+		t.Fatal("expected an error for an unknown request type")
+	}
+}
+
+func TestNewKeyDistributionUnknown(t *testing.T) {
+	cfg := &WorkloadConfig{KeyDist: "bogus"}
+	if _, err := NewKeyDistribution(cfg, 100); err == nil {
+		t.Fatal("expected an error for an unknown key distribution")
+	}
+}
+
+func TestNewValueSizeDistributionSelectsConstant(t *testing.T) {
+	cfg := &WorkloadConfig{ValueSizeDist: "constant", ValueSizeMin: 42}
+	dist, err := NewValueSizeDistribution(cfg)
+	if err != nil {
+		t.Fatalf("NewValueSizeDistribution: %s", err)
+	}
+	if dist == nil || dist.NextSize() != 42 {
+		t.Fatalf("NextSize() = %v, want 42", dist)
+	}
+}
+
+func TestNewValueSizeDistributionDefaultsToNil(t *testing.T) {
+	dist, err := NewValueSizeDistribution(&WorkloadConfig{})
+	if err != nil {
+		t.Fatalf("NewValueSizeDistribution: %s", err)
+	}
+	if dist != nil {
+		t.Fatalf("dist = %v, want nil when ValueSizeDist is unset", dist)
+	}
+}
+
+func TestRegisterWorkloadFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := RegisterWorkloadFlags(fs)
+
+	if err := fs.Parse([]string{"-key-dist", "zipfian", "-mix", "get=1.0"}); err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+	if cfg.KeyDist != "zipfian" {
+		t.Fatalf("KeyDist = %q, want %q", cfg.KeyDist, "zipfian")
+	}
+	if cfg.Mix != "get=1.0" {
+		t.Fatalf("Mix = %q, want %q", cfg.Mix, "get=1.0")
+	}
+}